@@ -0,0 +1,142 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package m3admin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+
+	retryhttp "github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	headerAuthorization = "Authorization"
+	headerHMACSignature = "X-M3-Signature"
+	headerHMACKeyID     = "X-M3-Signature-Key-Id"
+)
+
+// TokenSource returns a bearer token to send on each request, invoked fresh
+// for every call so it can serve a rotating token (e.g. a projected
+// ServiceAccount token refreshed off disk).
+type TokenSource func() (string, error)
+
+// WithTLSConfig installs a custom TLS configuration on the client's
+// underlying HTTP transport, for talking to coordinators behind mTLS or an
+// ingress controller with a private CA.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return optionFn(func(o *options) {
+		o.tlsConfig = cfg
+	})
+}
+
+// WithBearerTokenSource authenticates every request with a bearer token
+// pulled from src. src is called once per request, so it can hand back a
+// rotating token without the client needing to know how it's refreshed.
+// Callers can still override the Authorization header at a specific call
+// site with WithHeader.
+func WithBearerTokenSource(src TokenSource) Option {
+	return optionFn(func(o *options) {
+		o.tokenSource = src
+	})
+}
+
+// WithHMACSigner signs the body, canonical request line and canonical
+// headers of every request with HMAC-SHA256 under secret, identifying the
+// key with keyID via the X-M3-Signature-Key-Id header. Callers can still
+// override the signature headers at a specific call site with WithHeader.
+func WithHMACSigner(keyID string, secret []byte) Option {
+	return optionFn(func(o *options) {
+		o.hmacSigner = &hmacSigner{keyID: keyID, secret: secret}
+	})
+}
+
+type hmacSigner struct {
+	keyID  string
+	secret []byte
+}
+
+// canonicalHeaders returns a deterministic, signable representation of
+// header: one "lower(name):comma,joined,values\n" line per header name in
+// sorted order, excluding the signature headers themselves (which aren't
+// set yet when this is called, but are excluded for clarity and in case a
+// caller ever re-signs a request that already carries them).
+func canonicalHeaders(header http.Header) string {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		if k == headerHMACSignature || k == headerHMACKeyID {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(strings.ToLower(k))
+		b.WriteByte(':')
+		b.WriteString(strings.Join(header.Values(k), ","))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// sign computes the hex-encoded HMAC-SHA256 over the canonical request
+// (method, URL, headers and body), so the coordinator can verify the
+// request wasn't tampered with in transit - including a header like
+// Cluster-Environment-Name being altered or stripped by an intermediary.
+func (s *hmacSigner) sign(method, url string, header http.Header, body []byte) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(method))
+	mac.Write([]byte("\n"))
+	mac.Write([]byte(url))
+	mac.Write([]byte("\n"))
+	mac.Write([]byte(canonicalHeaders(header)))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// applyAuth adds the Authorization and/or HMAC signature headers configured
+// via WithBearerTokenSource/WithHMACSigner, unless the caller already set
+// them explicitly through a call-site RequestOption. It must run after all
+// other headers (including the environment and content-type headers) have
+// been set on request, and it sets the bearer token before signing, since
+// the HMAC signature covers all of them.
+func (c *client) applyAuth(request *retryhttp.Request, method, url string, body []byte) error {
+	if c.tokenSource != nil && request.Header.Get(headerAuthorization) == "" {
+		token, err := c.tokenSource()
+		if err != nil {
+			return err
+		}
+		request.Header.Set(headerAuthorization, "Bearer "+token)
+	}
+
+	if c.hmacSigner != nil && request.Header.Get(headerHMACSignature) == "" {
+		request.Header.Set(headerHMACSignature, c.hmacSigner.sign(method, url, request.Header, body))
+		request.Header.Set(headerHMACKeyID, c.hmacSigner.keyID)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package m3admin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithBearerTokenSource_SetsAuthorizationHeader(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithBearerTokenSource(func() (string, error) { return "mytoken", nil }))
+
+	_, err := c.DoHTTPRequest(context.Background(), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	require.Equal(t, "Bearer mytoken", gotAuth)
+}
+
+func TestWithBearerTokenSource_DoesNotOverrideCallerSuppliedHeader(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithBearerTokenSource(func() (string, error) { return "mytoken", nil }))
+
+	_, err := c.DoHTTPRequest(context.Background(), http.MethodGet, srv.URL, nil,
+		WithHeader("Authorization", "Bearer caller-supplied"))
+	require.NoError(t, err)
+	require.Equal(t, "Bearer caller-supplied", gotAuth)
+}
+
+func TestWithHMACSigner_SetsSignatureAndKeyIDHeaders(t *testing.T) {
+	var gotSig, gotKeyID string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig = r.Header.Get("X-M3-Signature")
+		gotKeyID = r.Header.Get("X-M3-Signature-Key-Id")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := NewClient(
+		WithHMACSigner("key-1", []byte("secret")),
+		WithEnvironment("staging"),
+	)
+
+	_, err := c.DoHTTPRequest(context.Background(), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	require.Equal(t, "key-1", gotKeyID)
+	require.NotEmpty(t, gotSig)
+}
+
+func TestHMACSigner_SignatureCoversHeaders(t *testing.T) {
+	s := &hmacSigner{keyID: "k", secret: []byte("secret")}
+
+	base := http.Header{}
+	base.Set(m3EnvironmentHeader, "staging")
+	baseSig := s.sign(http.MethodGet, "http://coordinator/namespace", base, nil)
+
+	tampered := http.Header{}
+	tampered.Set(m3EnvironmentHeader, "production")
+	tamperedSig := s.sign(http.MethodGet, "http://coordinator/namespace", tampered, nil)
+
+	require.NotEqual(t, baseSig, tamperedSig, "changing a signed header must change the signature")
+}
@@ -0,0 +1,323 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package m3admin
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrCircuitOpen is returned by DoHTTPRequest when the circuit breaker for
+// the request's host is open and the request was short-circuited without
+// being sent to the coordinator.
+var ErrCircuitOpen = errors.New("m3admin: circuit breaker open")
+
+const (
+	defaultWindowSize      = 10 * time.Second
+	defaultMinRequests     = 10
+	defaultFailureRatio    = 0.5
+	defaultCooldownPeriod  = 15 * time.Second
+	defaultHalfOpenAllowed = 1
+)
+
+// CircuitBreakerConfig configures the per-host circuit breaker installed by
+// WithCircuitBreaker.
+type CircuitBreakerConfig struct {
+	// WindowSize is the length of the rolling window over which the
+	// failure ratio is computed. Defaults to 10s.
+	WindowSize time.Duration
+
+	// MinRequests is the minimum number of requests that must land in the
+	// window before the breaker will consider tripping. Defaults to 10.
+	MinRequests int
+
+	// FailureRatio is the fraction of failed requests within the window
+	// (in [0,1]) above which the breaker opens. Defaults to 0.5.
+	FailureRatio float64
+
+	// CooldownPeriod is how long the breaker stays open before allowing
+	// probe requests (up to HalfOpenAllowed) through in the half-open
+	// state. Defaults to 15s.
+	CooldownPeriod time.Duration
+
+	// HalfOpenAllowed is the number of probe requests allowed through while
+	// half-open. The first probe outcome recorded closes the breaker on
+	// success or re-opens it on failure. Defaults to 1.
+	HalfOpenAllowed int
+}
+
+type circuitBreakerOptions struct {
+	cfg CircuitBreakerConfig
+}
+
+func (c CircuitBreakerConfig) withDefaults() CircuitBreakerConfig {
+	if c.WindowSize <= 0 {
+		c.WindowSize = defaultWindowSize
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = defaultMinRequests
+	}
+	if c.FailureRatio <= 0 {
+		c.FailureRatio = defaultFailureRatio
+	}
+	if c.CooldownPeriod <= 0 {
+		c.CooldownPeriod = defaultCooldownPeriod
+	}
+	if c.HalfOpenAllowed <= 0 {
+		c.HalfOpenAllowed = defaultHalfOpenAllowed
+	}
+	return c
+}
+
+// WithCircuitBreaker installs a per-host circuit breaker on the client.
+// Once the rolling error ratio for a host exceeds cfg.FailureRatio, the
+// breaker opens and DoHTTPRequest returns ErrCircuitOpen for that host until
+// the cooldown elapses, at which point a limited number of probe requests
+// are allowed through to test recovery.
+func WithCircuitBreaker(cfg CircuitBreakerConfig) Option {
+	return optionFn(func(o *options) {
+		o.breaker = &circuitBreakerOptions{cfg: cfg.withDefaults()}
+	})
+}
+
+// WithMaxInflight caps the number of concurrent in-flight requests the
+// client will send to a single host, queuing behind a semaphore once the
+// limit is reached. The limit is fixed at n; it does not adapt to observed
+// latencies or error rates.
+func WithMaxInflight(n int) Option {
+	return optionFn(func(o *options) {
+		o.maxInflight = n
+	})
+}
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+// hostBreaker tracks the rolling failure ratio and open/half-open/closed
+// state for a single coordinator host.
+type hostBreaker struct {
+	mu sync.Mutex
+
+	cfg CircuitBreakerConfig
+
+	state         breakerState
+	openedAt      time.Time
+	halfOpenInUse int
+
+	windowStart time.Time
+	successes   int
+	failures    int
+}
+
+func newHostBreaker(cfg CircuitBreakerConfig) *hostBreaker {
+	return &hostBreaker{
+		cfg:         cfg,
+		windowStart: time.Now(),
+	}
+}
+
+func (b *hostBreaker) resetWindowLocked(now time.Time) {
+	b.windowStart = now
+	b.successes = 0
+	b.failures = 0
+}
+
+// allow reports whether a request should be let through, transitioning the
+// breaker from open to half-open once the cooldown has elapsed.
+func (b *hostBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if now.Sub(b.openedAt) < b.cfg.CooldownPeriod {
+			return false
+		}
+		b.state = stateHalfOpen
+		b.halfOpenInUse = 0
+		fallthrough
+	case stateHalfOpen:
+		if b.halfOpenInUse >= b.cfg.HalfOpenAllowed {
+			return false
+		}
+		b.halfOpenInUse++
+		return true
+	default:
+		return true
+	}
+}
+
+// record updates the rolling counters with the outcome of a request that
+// was allowed through, opening or closing the breaker as appropriate.
+func (b *hostBreaker) record(now time.Time, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == stateHalfOpen {
+		if success {
+			b.state = stateClosed
+			b.resetWindowLocked(now)
+		} else {
+			b.state = stateOpen
+			b.openedAt = now
+		}
+		return
+	}
+
+	if now.Sub(b.windowStart) > b.cfg.WindowSize {
+		b.resetWindowLocked(now)
+	}
+
+	if success {
+		b.successes++
+	} else {
+		b.failures++
+	}
+
+	total := b.successes + b.failures
+	if total < b.cfg.MinRequests {
+		return
+	}
+
+	if float64(b.failures)/float64(total) > b.cfg.FailureRatio {
+		b.state = stateOpen
+		b.openedAt = now
+	}
+}
+
+// State returns the breaker's current state.
+func (b *hostBreaker) State() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// circuitBreaker fans requests out to a per-host hostBreaker. The per-host
+// inflight semaphores used by WithMaxInflight live in inflightLimiter.
+type circuitBreaker struct {
+	cfg CircuitBreakerConfig
+
+	mu       sync.Mutex
+	breakers map[string]*hostBreaker
+}
+
+func newCircuitBreaker(cfg CircuitBreakerConfig) *circuitBreaker {
+	return &circuitBreaker{
+		cfg:      cfg,
+		breakers: make(map[string]*hostBreaker),
+	}
+}
+
+func (c *circuitBreaker) hostBreaker(host string) *hostBreaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.breakers[host]
+	if !ok {
+		b = newHostBreaker(c.cfg)
+		c.breakers[host] = b
+	}
+	return b
+}
+
+type inflightLimiter struct {
+	max int
+
+	mu   sync.Mutex
+	sems map[string]chan struct{}
+}
+
+func newInflightLimiter(max int) *inflightLimiter {
+	return &inflightLimiter{max: max, sems: make(map[string]chan struct{})}
+}
+
+func (l *inflightLimiter) semaphore(host string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sem, ok := l.sems[host]
+	if !ok {
+		sem = make(chan struct{}, l.max)
+		l.sems[host] = sem
+	}
+	return sem
+}
+
+// acquire blocks until a slot for host is free and returns a func that
+// releases it.
+func (l *inflightLimiter) acquire(host string) func() {
+	sem := l.semaphore(host)
+	select {
+	case sem <- struct{}{}:
+	default:
+		inflightRejectedTotal.WithLabelValues(host).Inc()
+		sem <- struct{}{}
+	}
+	return func() { <-sem }
+}
+
+var (
+	breakerStateGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "m3admin",
+			Subsystem: "client",
+			Name:      "circuit_breaker_state",
+			Help:      "Current circuit breaker state per host (0=closed, 1=open, 2=half-open).",
+		},
+		[]string{"host"},
+	)
+
+	breakerShortCircuitedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "m3admin",
+			Subsystem: "client",
+			Name:      "circuit_breaker_short_circuited_total",
+			Help:      "Number of requests short-circuited by an open circuit breaker, per host.",
+		},
+		[]string{"host"},
+	)
+
+	inflightRejectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "m3admin",
+			Subsystem: "client",
+			Name:      "inflight_rejected_total",
+			Help:      "Number of requests that had to wait because WithMaxInflight's per-host limit was reached.",
+		},
+		[]string{"host"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(breakerStateGauge, breakerShortCircuitedTotal, inflightRejectedTotal)
+}
+
+func recordBreakerState(host string, state breakerState) {
+	breakerStateGauge.WithLabelValues(host).Set(float64(state))
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package m3admin
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		WindowSize:      time.Minute,
+		MinRequests:     4,
+		FailureRatio:    0.5,
+		CooldownPeriod:  time.Minute,
+		HalfOpenAllowed: 1,
+	}.withDefaults()
+}
+
+func TestHostBreaker_OpensOnceFailureRatioExceedsThresholdAfterMinRequests(t *testing.T) {
+	now := time.Now()
+	b := newHostBreaker(testBreakerConfig())
+
+	// Below MinRequests: a 100% failure ratio must not trip the breaker yet.
+	require.True(t, b.allow(now))
+	b.record(now, false)
+	require.Equal(t, stateClosed, b.State())
+
+	require.True(t, b.allow(now))
+	b.record(now, false)
+	require.Equal(t, stateClosed, b.State())
+
+	require.True(t, b.allow(now))
+	b.record(now, false)
+	require.Equal(t, stateClosed, b.State())
+
+	// Fourth request reaches MinRequests with a 100% failure ratio > 0.5.
+	require.True(t, b.allow(now))
+	b.record(now, false)
+	require.Equal(t, stateOpen, b.State())
+
+	// Further requests are short-circuited until the cooldown elapses.
+	require.False(t, b.allow(now))
+}
+
+func TestHostBreaker_StaysClosedBelowFailureRatio(t *testing.T) {
+	now := time.Now()
+	b := newHostBreaker(testBreakerConfig())
+
+	for i := 0; i < 3; i++ {
+		require.True(t, b.allow(now))
+		b.record(now, true)
+	}
+	require.True(t, b.allow(now))
+	b.record(now, false)
+
+	require.Equal(t, stateClosed, b.State())
+	require.True(t, b.allow(now))
+}
+
+func TestHostBreaker_HalfOpenProbeSucceedsCloses(t *testing.T) {
+	now := time.Now()
+	cfg := testBreakerConfig()
+	b := newHostBreaker(cfg)
+	b.state = stateOpen
+	b.openedAt = now.Add(-2 * cfg.CooldownPeriod)
+
+	probeTime := now
+	require.True(t, b.allow(probeTime))
+	require.Equal(t, stateHalfOpen, b.State())
+
+	// A second probe is rejected while the first is outstanding.
+	require.False(t, b.allow(probeTime))
+
+	b.record(probeTime, true)
+	require.Equal(t, stateClosed, b.State())
+	require.True(t, b.allow(probeTime))
+}
+
+func TestHostBreaker_HalfOpenProbeFailureReopens(t *testing.T) {
+	now := time.Now()
+	cfg := testBreakerConfig()
+	b := newHostBreaker(cfg)
+	b.state = stateOpen
+	b.openedAt = now.Add(-2 * cfg.CooldownPeriod)
+
+	probeTime := now
+	require.True(t, b.allow(probeTime))
+	require.Equal(t, stateHalfOpen, b.State())
+
+	b.record(probeTime, false)
+	require.Equal(t, stateOpen, b.State())
+	require.False(t, b.allow(probeTime))
+}
+
+func TestInflightLimiter_BlocksBeyondMaxAndReleasesOnReturnedFunc(t *testing.T) {
+	l := newInflightLimiter(1)
+
+	release := l.acquire("host")
+
+	acquired := make(chan struct{})
+	go func() {
+		second := l.acquire("host")
+		close(acquired)
+		second()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second acquire should have blocked while the first slot was held")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release()
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("second acquire should unblock once the first slot is released")
+	}
+}
+
+func TestInflightLimiter_PerHostIsolation(t *testing.T) {
+	l := newInflightLimiter(1)
+
+	releaseA := l.acquire("host-a")
+	defer releaseA()
+
+	done := make(chan struct{})
+	go func() {
+		releaseB := l.acquire("host-b")
+		releaseB()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("acquiring a different host's semaphore should not block on host-a's slot")
+	}
+}
+
+func TestInflightLimiter_ConcurrentAcquireNeverExceedsMax(t *testing.T) {
+	const max = 3
+	l := newInflightLimiter(max)
+
+	var mu sync.Mutex
+	current, peak := 0, 0
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := l.acquire("host")
+			mu.Lock()
+			current++
+			if current > peak {
+				peak = current
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			current--
+			mu.Unlock()
+			release()
+		}()
+	}
+
+	wg.Wait()
+	require.LessOrEqual(t, peak, max)
+}
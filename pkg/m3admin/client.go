@@ -22,16 +22,21 @@ package m3admin
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"crypto/tls"
 	"errors"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	retryhttp "github.com/hashicorp/go-retryablehttp"
-	pkgerrors "github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -53,14 +58,19 @@ var (
 
 // Client is an m3admin client.
 type Client interface {
-	DoHTTPRequest(action, url string, data *bytes.Buffer, opts ...RequestOption) (*http.Response, error)
-	DoHTTPJSONPBRequest(action, url string, request, response proto.Message, opts ...RequestOption) error
+	DoHTTPRequest(ctx context.Context, action, url string, data *bytes.Buffer, opts ...RequestOption) (*http.Response, error)
+	DoHTTPJSONPBRequest(ctx context.Context, action, url string, request, response proto.Message, opts ...RequestOption) error
 }
 
 type client struct {
 	client      *retryhttp.Client
 	logger      *zap.Logger
 	environment string
+	breaker     *circuitBreaker
+	inflight    *inflightLimiter
+	transport   Transport
+	tokenSource TokenSource
+	hmacSigner  *hmacSigner
 }
 
 type nullLogger struct{}
@@ -74,10 +84,21 @@ func NewClient(clientOpts ...Option) Client {
 		o.execute(opts)
 	}
 
+	if opts.transport == TransportGRPC {
+		// TransportGRPC is reserved for future use; failing loudly here,
+		// rather than only when a call is made, keeps it from looking like
+		// a third equally-supported choice alongside TransportJSONPB and
+		// TransportProtobuf.
+		panic("m3admin: WithTransport(TransportGRPC) is not yet supported, see ErrGRPCTransportNotSupported")
+	}
+
 	client := &client{
 		client:      opts.client,
 		logger:      opts.logger,
 		environment: opts.environment,
+		transport:   opts.transport,
+		tokenSource: opts.tokenSource,
+		hmacSigner:  opts.hmacSigner,
 	}
 
 	if client.client == nil {
@@ -86,6 +107,19 @@ func NewClient(clientOpts ...Option) Client {
 	if client.logger == nil {
 		client.logger = zap.NewNop()
 	}
+	if opts.breaker != nil {
+		client.breaker = newCircuitBreaker(opts.breaker.cfg)
+	}
+	if opts.maxInflight > 0 {
+		client.inflight = newInflightLimiter(opts.maxInflight)
+	}
+	if opts.tlsConfig != nil {
+		wireTLSConfig(client.client, opts.tlsConfig)
+	}
+	if client.client.HTTPClient == nil {
+		client.client.HTTPClient = &http.Client{}
+	}
+	client.client.HTTPClient.Transport = newTracingTransport(client.client.HTTPClient.Transport)
 
 	// We do our own request logging, silence their logger.
 	client.client.Logger = nullLogger{}
@@ -94,18 +128,81 @@ func NewClient(clientOpts ...Option) Client {
 	return client
 }
 
-// DoHTTPRequest is a simple helper for HTTP requests
+// wireTLSConfig installs cfg on rc's underlying *http.Client. An existing
+// *http.Transport is cloned so other transport settings a caller configured
+// via WithHTTPClient aren't lost, and a nil transport clones
+// http.DefaultTransport (rather than a bare &http.Transport{}) to keep its
+// defaults like ProxyFromEnvironment. A custom RoundTripper that isn't an
+// *http.Transport is left untouched, since there's no generic way to merge
+// a tls.Config into it; callers combining WithTLSConfig with that kind of
+// WithHTTPClient transport need to apply cfg themselves.
+func wireTLSConfig(rc *retryhttp.Client, cfg *tls.Config) {
+	if rc.HTTPClient == nil {
+		rc.HTTPClient = &http.Client{}
+	}
+
+	var transport *http.Transport
+	switch t := rc.HTTPClient.Transport.(type) {
+	case *http.Transport:
+		transport = t.Clone()
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	default:
+		return
+	}
+	transport.TLSClientConfig = cfg
+	rc.HTTPClient.Transport = transport
+}
+
+// DoHTTPRequest is a simple helper for HTTP requests. ctx is used both for
+// cancellation and as the parent of the span covering this logical call
+// (and, transitively, one child span per retry attempt); pass the
+// reconciler's context so coordinator calls show up nested under the
+// reconcile span instead of as orphaned traces.
 func (c *client) DoHTTPRequest(
+	ctx context.Context,
 	action, url string,
 	data *bytes.Buffer,
 	options ...RequestOption,
 ) (*http.Response, error) {
+	ctx, span := tracer.Start(ctx, "m3admin."+strings.ToLower(action),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("m3admin.url", url)),
+	)
+	defer span.End()
+
 	l := c.logger.With(zap.String("action", action), zap.String("url", url))
 	opts := &reqOptions{}
 	for _, o := range options {
 		o.execute(opts)
 	}
 
+	host := requestHost(url)
+
+	if c.breaker != nil {
+		b := c.breaker.hostBreaker(host)
+		if !b.allow(time.Now()) {
+			breakerShortCircuitedTotal.WithLabelValues(host).Inc()
+			l.Debug("circuit breaker open, short-circuiting request")
+			span.SetStatus(codes.Error, ErrCircuitOpen.Error())
+			return nil, ErrCircuitOpen
+		}
+	}
+
+	if c.inflight != nil {
+		release := c.inflight.acquire(host)
+		defer release()
+	}
+
+	recordOutcome := func(success bool) {
+		if c.breaker == nil {
+			return
+		}
+		b := c.breaker.hostBreaker(host)
+		b.record(time.Now(), success)
+		recordBreakerState(host, b.State())
+	}
+
 	var request *retryhttp.Request
 	var err error
 
@@ -114,11 +211,13 @@ func (c *client) DoHTTPRequest(
 	if data == nil {
 		request, err = retryhttp.NewRequest(action, url, nil)
 		if err != nil {
+			recordOutcome(false)
 			return nil, err
 		}
 	} else {
 		request, err = retryhttp.NewRequest(action, url, data)
 		if err != nil {
+			recordOutcome(false)
 			return nil, err
 		}
 	}
@@ -129,11 +228,25 @@ func (c *client) DoHTTPRequest(
 		}
 	}
 
-	request.Header.Add("Content-Type", "application/json")
+	if _, ok := opts.headers["Content-Type"]; !ok {
+		request.Header.Add("Content-Type", "application/json")
+	}
 	if c.environment != "" {
 		request.Header.Add(m3EnvironmentHeader, c.environment)
 	}
 
+	var body []byte
+	if data != nil {
+		body = data.Bytes()
+	}
+	if err := c.applyAuth(request, action, url, body); err != nil {
+		recordOutcome(false)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	request.Request = request.Request.WithContext(withRequestMetadata(ctx, action, c.environment))
+
 	if l.Core().Enabled(zapcore.DebugLevel) {
 		dump, err := httputil.DumpRequest(request.Request, true)
 		if err != nil {
@@ -145,6 +258,9 @@ func (c *client) DoHTTPRequest(
 
 	response, err := c.client.Do(request)
 	if err != nil {
+		recordOutcome(false)
+		span.SetStatus(codes.Error, err.Error())
+		span.RecordError(err)
 		l.Debug("request error", zap.Error(err))
 		return nil, err
 	}
@@ -166,45 +282,62 @@ func (c *client) DoHTTPRequest(
 
 	code := response.StatusCode
 	if code >= 200 && code < 300 {
+		recordOutcome(true)
 		return response, nil
 	}
 
-	// attempt to parse our error message
-	errMsg, err := parseResponseError(response)
-	if err != nil {
-		l.Debug("error parsing error response", zap.Error(err))
-	}
-
-	if response.StatusCode == http.StatusNotFound {
-		return nil, pkgerrors.WithMessage(ErrNotFound, errMsg)
-	}
-
-	if response.StatusCode == http.StatusMethodNotAllowed {
-		return nil, pkgerrors.WithMessage(ErrMethodNotAllowed, errMsg)
-	}
+	recordOutcome(code < 500)
 
-	return nil, pkgerrors.WithMessage(ErrNotOk, errMsg)
+	// Decode the coordinator's JSON error envelope into a typed APIError so
+	// callers can branch on Code/Details instead of string matching, while
+	// still satisfying errors.Is against the legacy sentinel errors
+	// (ErrNotFound, ErrMethodNotAllowed, ErrNotOk) declared above.
+	apiErr := parseAPIError(response)
+	span.SetStatus(codes.Error, apiErr.Error())
+	return nil, apiErr
 }
 
 // DoHTTPJSONPBRequest is a helper for performing a request and
-// parsing the response as a JSONPB message into the response.
-// Both request and response are optional and can be emitted if
-// not wanting to either send or receive message.
+// parsing the response as a proto message into the response. Both request
+// and response are optional and can be emitted if not wanting to either
+// send or receive message. The wire format used is determined by the
+// client's WithTransport option (JSONPB by default); when TransportProtobuf
+// is selected but the coordinator responds with a JSON body, the response
+// is decoded as JSONPB to stay compatible with older coordinators.
 func (c *client) DoHTTPJSONPBRequest(
+	ctx context.Context,
 	action, url string,
 	request proto.Message,
 	response proto.Message,
 	opts ...RequestOption,
 ) error {
+	if c.transport == TransportGRPC {
+		return ErrGRPCTransportNotSupported
+	}
+	useProtobuf := c.transport == TransportProtobuf
+
 	var data *bytes.Buffer
 	if request != nil {
 		data = bytes.NewBuffer(nil)
-		if err := JSONPBMarshal(data, request); err != nil {
+		if useProtobuf {
+			b, err := proto.Marshal(request)
+			if err != nil {
+				return err
+			}
+			data.Write(b)
+		} else if err := JSONPBMarshal(data, request); err != nil {
 			return err
 		}
 	}
 
-	r, err := c.DoHTTPRequest(action, url, data, opts...)
+	if useProtobuf {
+		opts = append([]RequestOption{
+			WithHeader("Content-Type", contentTypeProtobuf),
+			WithHeader("Accept", contentTypeProtobuf+", "+contentTypeJSON),
+		}, opts...)
+	}
+
+	r, err := c.DoHTTPRequest(ctx, action, url, data, opts...)
 	if err != nil {
 		return err
 	}
@@ -219,23 +352,24 @@ func (c *client) DoHTTPJSONPBRequest(
 		return nil
 	}
 
+	if useProtobuf && !isJSONContentType(r.Header.Get("Content-Type")) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		return proto.Unmarshal(body, response)
+	}
+
 	return JSONPBUnmarshal(r.Body, response)
 }
 
-func parseResponseError(r *http.Response) (string, error) {
-	defer func() {
-		io.Copy(ioutil.Discard, r.Body)
-		r.Body.Close()
-	}()
-
-	respErr := struct {
-		Error string `json:"error"`
-	}{}
-
-	err := json.NewDecoder(r.Body).Decode(&respErr)
-	if err != nil {
-		return "", err
+// requestHost extracts the host:port used to key the circuit breaker and
+// inflight limiter for a request. Malformed URLs fall back to the raw URL
+// so they still get their own independent breaker/limiter.
+func requestHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
 	}
-
-	return respErr.Error, nil
+	return u.Host
 }
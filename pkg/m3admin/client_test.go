@@ -0,0 +1,129 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package m3admin
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	retryhttp "github.com/hashicorp/go-retryablehttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoHTTPRequest_DoesNotDuplicateContentTypeForProtobufTransport(t *testing.T) {
+	var gotContentType []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotContentType = r.Header.Values("Content-Type")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithTransport(TransportProtobuf)).(*client)
+
+	err := c.DoHTTPJSONPBRequest(context.Background(), http.MethodPost, srv.URL, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{contentTypeProtobuf}, gotContentType)
+}
+
+func TestDoHTTPRequest_DefaultsContentTypeToJSONWhenUnset(t *testing.T) {
+	var gotContentType []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotContentType = r.Header.Values("Content-Type")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := NewClient().(*client)
+
+	_, err := c.DoHTTPRequest(context.Background(), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{contentTypeJSON}, gotContentType)
+}
+
+var errTokenSource = errors.New("token source unavailable")
+
+func TestDoHTTPRequest_AuthFailureReleasesHalfOpenProbeSlot(t *testing.T) {
+	c := NewClient(
+		WithCircuitBreaker(CircuitBreakerConfig{HalfOpenAllowed: 1}),
+		WithBearerTokenSource(func() (string, error) { return "", errTokenSource }),
+	).(*client)
+
+	const url = "http://coordinator.invalid/namespace"
+	hb := c.breaker.hostBreaker(requestHost(url))
+	hb.state = stateHalfOpen
+
+	_, err := c.DoHTTPRequest(context.Background(), http.MethodGet, url, nil)
+	require.ErrorIs(t, err, errTokenSource)
+
+	// The failed probe should have reopened the breaker rather than leaving
+	// halfOpenInUse pinned at HalfOpenAllowed forever.
+	require.Equal(t, stateOpen, hb.State())
+}
+
+func TestNewClient_PanicsOnTransportGRPC(t *testing.T) {
+	require.Panics(t, func() {
+		NewClient(WithTransport(TransportGRPC))
+	})
+}
+
+func TestWireTLSConfig_NilTransportPreservesDefaultTransportSettings(t *testing.T) {
+	rc := retryhttp.NewClient()
+	rc.HTTPClient = &http.Client{}
+	cfg := &tls.Config{ServerName: "coordinator.example.com"}
+
+	wireTLSConfig(rc, cfg)
+
+	transport, ok := rc.HTTPClient.Transport.(*http.Transport)
+	require.True(t, ok)
+	require.Same(t, cfg, transport.TLSClientConfig)
+	require.NotNil(t, transport.Proxy, "cloning http.DefaultTransport should keep ProxyFromEnvironment")
+}
+
+func TestWireTLSConfig_ClonesExistingHTTPTransportSettings(t *testing.T) {
+	rc := retryhttp.NewClient()
+	existing := &http.Transport{MaxIdleConns: 7}
+	rc.HTTPClient = &http.Client{Transport: existing}
+	cfg := &tls.Config{ServerName: "coordinator.example.com"}
+
+	wireTLSConfig(rc, cfg)
+
+	transport, ok := rc.HTTPClient.Transport.(*http.Transport)
+	require.True(t, ok)
+	require.Same(t, cfg, transport.TLSClientConfig)
+	require.Equal(t, 7, transport.MaxIdleConns)
+	require.NotSame(t, existing, transport, "the original transport must not be mutated in place")
+}
+
+func TestWireTLSConfig_LeavesCustomRoundTripperUntouched(t *testing.T) {
+	rc := retryhttp.NewClient()
+	custom := roundTripFunc(func(*http.Request) (*http.Response, error) { return nil, nil })
+	rc.HTTPClient = &http.Client{Transport: custom}
+
+	wireTLSConfig(rc, &tls.Config{})
+
+	require.NotNil(t, rc.HTTPClient.Transport)
+	_, isHTTPTransport := rc.HTTPClient.Transport.(*http.Transport)
+	require.False(t, isHTTPTransport)
+}
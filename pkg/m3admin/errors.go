@@ -0,0 +1,174 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package m3admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// FieldViolation describes a single field-level validation error returned
+// by a placement or namespace endpoint.
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// APIError is the structured representation of a non-2xx response from
+// m3coordinator. It decodes the coordinator's JSON error envelope,
+// including the gRPC-style status code and any per-field validation
+// details, so callers can branch on Code/Details instead of string
+// matching Message.
+type APIError struct {
+	// HTTPStatus is the HTTP status code of the response.
+	HTTPStatus int
+
+	// Code is the gRPC-style status code reported by the coordinator, if
+	// any (e.g. "NOT_FOUND", "ALREADY_EXISTS").
+	Code string
+
+	// Message is the human-readable error message.
+	Message string
+
+	// Details holds any additional structured detail strings the
+	// coordinator attached to the error.
+	Details []string
+
+	// Fields holds any per-field validation errors attached to the error.
+	Fields []FieldViolation
+}
+
+// Unwrap allows callers still matching against the legacy sentinel errors
+// (ErrNotFound, ErrMethodNotAllowed, ErrNotOk) with errors.Is to keep
+// working against the new APIError type.
+func (e *APIError) Unwrap() error {
+	switch e.HTTPStatus {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusMethodNotAllowed:
+		return ErrMethodNotAllowed
+	default:
+		return ErrNotOk
+	}
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("m3admin: %s: %s (http %d)", e.Code, e.Message, e.HTTPStatus)
+	}
+	return fmt.Sprintf("m3admin: %s (http %d)", e.Message, e.HTTPStatus)
+}
+
+// coordinatorErrorEnvelope mirrors the JSON error body m3coordinator emits.
+// The plain "error" field is kept for backwards compatibility with older
+// coordinators that predate the richer envelope.
+type coordinatorErrorEnvelope struct {
+	Error   string           `json:"error"`
+	Code    string           `json:"code"`
+	Message string           `json:"message"`
+	Details []string         `json:"details"`
+	Fields  []FieldViolation `json:"fields"`
+}
+
+// parseAPIError decodes a non-2xx response body into an APIError, closing
+// the response body when done. It never returns a nil error value; if the
+// body can't be decoded as JSON, Message falls back to the raw body text.
+func parseAPIError(r *http.Response) *APIError {
+	defer func() {
+		io.Copy(ioutil.Discard, r.Body)
+		r.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return &APIError{HTTPStatus: r.StatusCode, Message: err.Error()}
+	}
+
+	var env coordinatorErrorEnvelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return &APIError{HTTPStatus: r.StatusCode, Message: string(body)}
+	}
+
+	msg := env.Message
+	if msg == "" {
+		msg = env.Error
+	}
+
+	return &APIError{
+		HTTPStatus: r.StatusCode,
+		Code:       env.Code,
+		Message:    msg,
+		Details:    env.Details,
+		Fields:     env.Fields,
+	}
+}
+
+// IsPlacementAlreadyExists returns true if err indicates the placement
+// already exists.
+func IsPlacementAlreadyExists(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == "ALREADY_EXISTS" || apiErr.HTTPStatus == http.StatusConflict
+	}
+	return false
+}
+
+// IsNotFound returns true if err indicates that some requested resource
+// was not found. APIError carries no resource-type information, so this
+// cannot distinguish which kind of resource was missing; IsNamespaceNotFound
+// and IsInstanceNotFound are both implemented in terms of this and are
+// currently equivalent to it for that reason.
+func IsNotFound(err error) bool {
+	return hasCode(err, "NOT_FOUND") || errors.Is(err, ErrNotFound)
+}
+
+// IsNamespaceNotFound returns true if err indicates the requested namespace
+// was not found. NOTE: APIError doesn't currently carry enough information
+// to tell a namespace 404 apart from any other resource's, so this is
+// presently equivalent to IsInstanceNotFound and IsNotFound; callers that
+// need that distinction must inspect err's Details themselves.
+func IsNamespaceNotFound(err error) bool {
+	return IsNotFound(err)
+}
+
+// IsInstanceNotFound returns true if err indicates the requested instance
+// was not found. NOTE: APIError doesn't currently carry enough information
+// to tell an instance 404 apart from any other resource's, so this is
+// presently equivalent to IsNamespaceNotFound and IsNotFound; callers that
+// need that distinction must inspect err's Details themselves.
+func IsInstanceNotFound(err error) bool {
+	return IsNotFound(err)
+}
+
+// hasCode returns true if err is (or wraps) an *APIError with the given
+// gRPC-style code.
+func hasCode(err error, code string) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == code
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package m3adminfake provides a fake implementation of m3admin.Client for
+// use in controller unit tests, built the same way the generated
+// clientset/fake package is: on top of client-go's testing.Fake action
+// recorder and reactor chain.
+package m3adminfake
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/testing"
+
+	"github.com/m3db/m3db-operator/pkg/m3admin"
+)
+
+// Action records a single call made through the Client interface. It
+// embeds testing.ActionImpl so it can be inspected and filtered with the
+// same helpers (action.Matches, etc.) used against client-go fakes.
+type Action struct {
+	testing.ActionImpl
+
+	// URL is the request URL the call was made against.
+	URL string
+
+	// Body is the raw request payload sent to DoHTTPRequest, if any.
+	Body []byte
+
+	// Request is the decoded proto request message passed to
+	// DoHTTPJSONPBRequest, if any.
+	Request proto.Message
+
+	// Headers are the headers assembled from the call's RequestOptions.
+	Headers map[string]string
+
+	// Environment is the Cluster-Environment-Name the fake client was
+	// constructed with.
+	Environment string
+}
+
+// DeepCopy implements testing.Action.
+func (a Action) DeepCopy() testing.Action {
+	var body []byte
+	if a.Body != nil {
+		body = append([]byte(nil), a.Body...)
+	}
+
+	var headers map[string]string
+	if a.Headers != nil {
+		headers = make(map[string]string, len(a.Headers))
+		for k, v := range a.Headers {
+			headers[k] = v
+		}
+	}
+
+	var request proto.Message
+	if a.Request != nil {
+		request = proto.Clone(a.Request)
+	}
+
+	return Action{
+		ActionImpl:  a.ActionImpl,
+		URL:         a.URL,
+		Body:        body,
+		Request:     request,
+		Headers:     headers,
+		Environment: a.Environment,
+	}
+}
+
+// newAction builds the Action recorded for a single client call. resource
+// is derived from the last non-empty path segment of the URL (e.g.
+// "placement", "namespace") so that reactors can filter with
+// action.Matches("post", "placement") the same way they would against a
+// real client-go fake.
+func newAction(method, url string, opts []m3admin.RequestOption, environment string) Action {
+	return Action{
+		ActionImpl: testing.ActionImpl{
+			Verb:     strings.ToLower(method),
+			Resource: schema.GroupVersionResource{Resource: resourceFromURL(url)},
+		},
+		URL:         url,
+		Headers:     m3admin.RequestHeaders(opts...),
+		Environment: environment,
+	}
+}
+
+func resourceFromURL(url string) string {
+	url = strings.SplitN(url, "?", 2)[0]
+	segments := strings.Split(strings.Trim(url, "/"), "/")
+	if len(segments) == 0 {
+		return ""
+	}
+	return segments[len(segments)-1]
+}
+
+// FakeClient is an m3admin.Client backed by client-go's testing.Fake. Every
+// call is recorded as an Action; install reactors with PrependReactor to
+// return canned responses or errors instead of hitting a real coordinator.
+type FakeClient struct {
+	*testing.Fake
+
+	environment string
+}
+
+var _ m3admin.Client = (*FakeClient)(nil)
+
+// NewFakeClient returns a FakeClient with no reactors installed. Callers
+// should install their own with PrependReactor before exercising code
+// under test, otherwise calls return a nil response/error.
+func NewFakeClient(environment string) *FakeClient {
+	return &FakeClient{
+		Fake:        &testing.Fake{},
+		environment: environment,
+	}
+}
+
+// DoHTTPRequest implements m3admin.Client.
+func (f *FakeClient) DoHTTPRequest(
+	_ context.Context,
+	action, url string,
+	data *bytes.Buffer,
+	opts ...m3admin.RequestOption,
+) (*http.Response, error) {
+	act := newAction(action, url, opts, f.environment)
+	if data != nil {
+		act.Body = data.Bytes()
+	}
+
+	obj, err := f.Fake.Invokes(act, runtime.Object(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, _ := obj.(*fakeHTTPResponse)
+	if resp == nil {
+		return nil, nil
+	}
+	return resp.response, nil
+}
+
+// DoHTTPJSONPBRequest implements m3admin.Client.
+func (f *FakeClient) DoHTTPJSONPBRequest(
+	_ context.Context,
+	action, url string,
+	request, response proto.Message,
+	opts ...m3admin.RequestOption,
+) error {
+	act := newAction(action, url, opts, f.environment)
+	act.Request = request
+
+	obj, err := f.Fake.Invokes(act, runtime.Object(nil))
+	if err != nil {
+		return err
+	}
+
+	canned, _ := obj.(*fakeProtoResponse)
+	if canned == nil || canned.message == nil || response == nil {
+		return nil
+	}
+	proto.Merge(response, canned.message)
+	return nil
+}
+
+// fakeHTTPResponse and fakeProtoResponse wrap the two shapes of canned
+// response a reactor can hand back through testing.Fake's runtime.Object
+// return value.
+type fakeHTTPResponse struct {
+	runtime.Object
+	response *http.Response
+}
+
+type fakeProtoResponse struct {
+	runtime.Object
+	message proto.Message
+}
+
+// NewHTTPResponseObject wraps an *http.Response so a reactor can return it
+// from DoHTTPRequest.
+func NewHTTPResponseObject(resp *http.Response) runtime.Object {
+	return &fakeHTTPResponse{response: resp}
+}
+
+// NewProtoResponseObject wraps a proto.Message so a reactor can return it
+// from DoHTTPJSONPBRequest.
+func NewProtoResponseObject(msg proto.Message) runtime.Object {
+	return &fakeProtoResponse{message: msg}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package m3adminfake
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/m3db/m3db-operator/pkg/m3admin"
+)
+
+func TestFakeClient_RecordsActions(t *testing.T) {
+	c := NewFakeClient("staging")
+
+	body := bytes.NewBufferString(`{"foo":"bar"}`)
+	_, err := c.DoHTTPRequest(context.Background(), http.MethodPost, "http://coordinator/api/v1/placement", body,
+		m3admin.WithHeader("X-Test", "1"))
+	require.NoError(t, err)
+
+	actions := c.Actions()
+	require.Len(t, actions, 1)
+
+	act, ok := actions[0].(Action)
+	require.True(t, ok)
+	require.Equal(t, "post", act.Verb)
+	require.Equal(t, "placement", act.Resource.Resource)
+	require.Equal(t, "http://coordinator/api/v1/placement", act.URL)
+	require.Equal(t, []byte(`{"foo":"bar"}`), act.Body)
+	require.Equal(t, "1", act.Headers["X-Test"])
+	require.Equal(t, "staging", act.Environment)
+}
+
+func TestFakeClient_ReactorReturnsCannedHTTPResponse(t *testing.T) {
+	c := NewFakeClient("")
+
+	want := &http.Response{StatusCode: http.StatusAccepted}
+	c.PrependReactor("post", "placement", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, NewHTTPResponseObject(want), nil
+	})
+
+	resp, err := c.DoHTTPRequest(context.Background(), http.MethodPost, "http://coordinator/api/v1/placement", nil)
+	require.NoError(t, err)
+	require.Same(t, want, resp)
+}
+
+func TestFakeClient_ReactorReturnsError(t *testing.T) {
+	c := NewFakeClient("")
+
+	wantErr := errors.New("coordinator unreachable")
+	c.PrependReactor("post", "placement", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, wantErr
+	})
+
+	_, err := c.DoHTTPRequest(context.Background(), http.MethodPost, "http://coordinator/api/v1/placement", nil)
+	require.ErrorIs(t, err, wantErr)
+}
+
+func TestFakeClient_NoReactorInstalledReturnsNilResponse(t *testing.T) {
+	c := NewFakeClient("")
+
+	resp, err := c.DoHTTPRequest(context.Background(), http.MethodGet, "http://coordinator/api/v1/placement", nil)
+	require.NoError(t, err)
+	require.Nil(t, resp)
+}
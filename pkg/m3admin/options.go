@@ -0,0 +1,110 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package m3admin
+
+import (
+	"crypto/tls"
+
+	retryhttp "github.com/hashicorp/go-retryablehttp"
+	"go.uber.org/zap"
+)
+
+// options holds the configuration assembled from a set of Option values
+// passed to NewClient.
+type options struct {
+	client      *retryhttp.Client
+	logger      *zap.Logger
+	environment string
+	breaker     *circuitBreakerOptions
+	maxInflight int
+	transport   Transport
+	tlsConfig   *tls.Config
+	tokenSource TokenSource
+	hmacSigner  *hmacSigner
+}
+
+// Option configures the m3admin Client returned by NewClient.
+type Option interface {
+	execute(*options)
+}
+
+type optionFn func(*options)
+
+func (f optionFn) execute(o *options) { f(o) }
+
+// WithHTTPClient sets the retryablehttp client used to perform requests.
+func WithHTTPClient(c *retryhttp.Client) Option {
+	return optionFn(func(o *options) {
+		o.client = c
+	})
+}
+
+// WithLogger sets the logger used by the client.
+func WithLogger(logger *zap.Logger) Option {
+	return optionFn(func(o *options) {
+		o.logger = logger
+	})
+}
+
+// WithEnvironment sets the value sent on every request in the
+// Cluster-Environment-Name header.
+func WithEnvironment(env string) Option {
+	return optionFn(func(o *options) {
+		o.environment = env
+	})
+}
+
+// reqOptions holds the per-request configuration assembled from a set of
+// RequestOption values.
+type reqOptions struct {
+	headers map[string]string
+}
+
+// RequestOption configures a single request made through the Client.
+type RequestOption interface {
+	execute(*reqOptions)
+}
+
+type requestOptionFn func(*reqOptions)
+
+func (f requestOptionFn) execute(o *reqOptions) { f(o) }
+
+// WithHeader adds a header to be sent with the request, overriding any
+// existing value set for the same key by earlier options.
+func WithHeader(key, value string) RequestOption {
+	return requestOptionFn(func(o *reqOptions) {
+		if o.headers == nil {
+			o.headers = make(map[string]string)
+		}
+		o.headers[key] = value
+	})
+}
+
+// RequestHeaders applies opts and returns the resulting header set, without
+// performing a request. It is exported so packages such as m3adminfake can
+// record what a real call would have sent.
+func RequestHeaders(opts ...RequestOption) map[string]string {
+	o := &reqOptions{}
+	for _, opt := range opts {
+		opt.execute(o)
+	}
+	return o.headers
+}
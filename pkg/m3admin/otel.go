@@ -0,0 +1,152 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package m3admin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies this package's spans/instrumentation scope.
+const tracerName = "github.com/m3db/m3db-operator/pkg/m3admin"
+
+var tracer = otel.Tracer(tracerName)
+
+var (
+	requestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "m3admin",
+			Subsystem: "client",
+			Name:      "requests_total",
+			Help:      "Total requests made to m3coordinator, per action/host/status class/environment.",
+		},
+		[]string{"action", "host", "status_class", "environment"},
+	)
+
+	requestErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "m3admin",
+			Subsystem: "client",
+			Name:      "request_errors_total",
+			Help:      "Requests to m3coordinator that failed at the transport level (no response), per action/host/environment.",
+		},
+		[]string{"action", "host", "environment"},
+	)
+
+	requestLatencySeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "m3admin",
+			Subsystem: "client",
+			Name:      "request_latency_seconds",
+			Help:      "Latency of individual HTTP attempts against m3coordinator, per action/host/status class/environment.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"action", "host", "status_class", "environment"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal, requestErrorsTotal, requestLatencySeconds)
+}
+
+// requestMetadataKey is the context key type used to thread the current
+// action/environment down to the transport, which only sees *http.Request.
+type requestMetadataKey struct{}
+
+type requestMetadata struct {
+	action      string
+	environment string
+}
+
+func withRequestMetadata(ctx context.Context, action, environment string) context.Context {
+	return context.WithValue(ctx, requestMetadataKey{}, requestMetadata{action: action, environment: environment})
+}
+
+func requestMetadataFromContext(ctx context.Context) requestMetadata {
+	md, _ := ctx.Value(requestMetadataKey{}).(requestMetadata)
+	return md
+}
+
+// tracingTransport wraps a base http.RoundTripper with an OpenTelemetry
+// span and RED metrics per HTTP attempt, and injects the current trace
+// context as a W3C traceparent header. Since retryablehttp.Client re-runs
+// RoundTrip once per retry attempt (all sharing the parent request's
+// context), this naturally produces one child span per retry underneath
+// the logical call span started by DoHTTPRequest.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func newTracingTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &tracingTransport{base: base}
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	md := requestMetadataFromContext(req.Context())
+	host := req.URL.Host
+
+	ctx, span := tracer.Start(req.Context(), "m3admin.attempt",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("m3admin.action", md.action),
+			attribute.String("net.peer.name", host),
+			attribute.String("m3admin.environment", md.environment),
+		),
+	)
+	defer span.End()
+
+	req = req.WithContext(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start).Seconds()
+
+	statusClass := "error"
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		span.RecordError(err)
+		requestErrorsTotal.WithLabelValues(md.action, host, md.environment).Inc()
+	} else {
+		statusClass = strconv.Itoa(resp.StatusCode/100) + "xx"
+		span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+		if resp.StatusCode >= 400 {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	}
+
+	requestsTotal.WithLabelValues(md.action, host, statusClass, md.environment).Inc()
+	requestLatencySeconds.WithLabelValues(md.action, host, statusClass, md.environment).Observe(elapsed)
+
+	return resp, err
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package m3admin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// withTestTracerProvider installs an in-memory span recorder as the global
+// TracerProvider/propagator for the duration of the test and returns the
+// exporter spans end up in, restoring the previous globals on cleanup. The
+// package's `tracer` var was resolved from otel.Tracer at init time, but
+// that handle forwards to whatever provider is globally installed when a
+// span is actually started, so swapping the global here is enough to
+// observe spans created by DoHTTPRequest/tracingTransport.
+func withTestTracerProvider(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	prevTP := otel.GetTracerProvider()
+	prevProp := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	return exporter
+}
+
+func TestDoHTTPRequest_ChildSpanUnderPassedInParent(t *testing.T) {
+	exporter := withTestTracerProvider(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+
+	parentCtx, parentSpan := tracer.Start(context.Background(), "reconcile")
+	_, err := c.DoHTTPRequest(parentCtx, http.MethodGet, srv.URL, nil)
+	parentSpan.End()
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3) // reconcile, m3admin.get, m3admin.attempt
+
+	byName := make(map[string]tracetest.SpanStub, len(spans))
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+
+	parentStub, ok := byName["reconcile"]
+	require.True(t, ok)
+	logicalCall, ok := byName["m3admin.get"]
+	require.True(t, ok)
+	attempt, ok := byName["m3admin.attempt"]
+	require.True(t, ok)
+
+	require.Equal(t, parentStub.SpanContext.TraceID(), logicalCall.SpanContext.TraceID())
+	require.Equal(t, parentStub.SpanContext.SpanID(), logicalCall.Parent.SpanID())
+	require.Equal(t, logicalCall.SpanContext.TraceID(), attempt.SpanContext.TraceID())
+	require.Equal(t, logicalCall.SpanContext.SpanID(), attempt.Parent.SpanID())
+}
+
+func TestTracingTransport_InjectsTraceparentHeader(t *testing.T) {
+	withTestTracerProvider(t)
+
+	var gotTraceparent string
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		gotTraceparent = req.Header.Get("traceparent")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+
+	transport := newTracingTransport(base)
+
+	ctx, span := tracer.Start(context.Background(), "m3admin.get")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://coordinator.invalid/namespace", nil)
+	require.NoError(t, err)
+
+	_, err = transport.RoundTrip(req)
+	require.NoError(t, err)
+	require.NotEmpty(t, gotTraceparent)
+	require.Contains(t, gotTraceparent, span.SpanContext().TraceID().String())
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }